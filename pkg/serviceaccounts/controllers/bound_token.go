@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/kubernetes/pkg/api"
+	authenticationv1 "k8s.io/kubernetes/pkg/apis/authentication/v1"
+	"k8s.io/kubernetes/pkg/client/retry"
+	"k8s.io/kubernetes/pkg/client/typed/discovery"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/credentialprovider"
+	utilruntime "k8s.io/kubernetes/pkg/util/runtime"
+)
+
+// BoundTokenExpirationKey records the RFC3339 expiration timestamp of the bound token
+// embedded in a dockercfg secret created in BoundTokenRequest mode.
+const BoundTokenExpirationKey = "openshift.io/token-secret.expiration"
+
+// boundTokenRefreshCheckInterval is how often refreshBoundTokens sweeps dockercfg secrets
+// looking for bound tokens within their RefreshBefore window.
+const boundTokenRefreshCheckInterval = 1 * time.Minute
+
+// boundTokenRequestGroupVersion is the discovery group/version checked to decide whether the
+// API server supports the TokenRequest API. The serviceaccounts/token subresource is served
+// alongside serviceaccounts in the core v1 group.
+const boundTokenRequestGroupVersion = "v1"
+
+// TokenMode selects how DockercfgController obtains the bearer credential it embeds in
+// generated dockercfg secrets.
+type TokenMode string
+
+const (
+	// LegacySecret creates a long-lived, never-expiring SecretTypeServiceAccountToken secret
+	// and waits for the token controller to populate it. This is the default, for backward
+	// compatibility with clusters that predate the TokenRequest API.
+	LegacySecret TokenMode = "LegacySecret"
+
+	// BoundTokenRequest mints a bound, time-limited token via the TokenRequest API and embeds
+	// it directly in the dockercfg secret, without ever creating a token secret. A background
+	// loop re-mints the token as it approaches expiration.
+	BoundTokenRequest TokenMode = "BoundTokenRequest"
+)
+
+// boundTokenRequestSupported reports whether the API server advertises the
+// serviceaccounts/token subresource used by the TokenRequest API. A nil discoveryClient, or
+// any discovery error, is treated as unsupported so callers fail safe to LegacySecret.
+func boundTokenRequestSupported(discoveryClient discovery.DiscoveryInterface) bool {
+	if discoveryClient == nil {
+		return false
+	}
+
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(boundTokenRequestGroupVersion)
+	if err != nil {
+		glog.V(2).Infof("error discovering TokenRequest support: %v", err)
+		return false
+	}
+
+	for _, resource := range resources.APIResources {
+		if resource.Name == "serviceaccounts/token" {
+			return true
+		}
+	}
+	return false
+}
+
+// BoundTokenMinter abstracts minting a bound, time-limited ServiceAccount token for
+// TokenMode: BoundTokenRequest. DockercfgControllerOptions.TokenMinter may be set to override
+// the default, but NewDockercfgController otherwise constructs tokenRequestMinter itself
+// against the TokenRequest subresource of the client it's given.
+type BoundTokenMinter interface {
+	// MintToken returns a bearer token for the named ServiceAccount, scoped to the given
+	// audiences and expiring after ttlSeconds (nil means the implementation's own default),
+	// along with the token's expiration time.
+	MintToken(namespace, saName string, audiences []string, ttlSeconds *int64) (token string, expiresAt time.Time, err error)
+}
+
+// tokenRequestMinter is the default BoundTokenMinter: it mints bound tokens by calling the
+// serviceaccounts/token subresource directly, the way upstream's TokenRequest-aware kubelet
+// credential providers do.
+type tokenRequestMinter struct {
+	client client.Interface
+}
+
+func (m *tokenRequestMinter) MintToken(namespace, saName string, audiences []string, ttlSeconds *int64) (string, time.Time, error) {
+	tokenRequest, err := m.client.ServiceAccounts(namespace).CreateToken(saName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: ttlSeconds,
+		},
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenRequest.Status.Token, tokenRequest.Status.ExpirationTimestamp.Time, nil
+}
+
+// mintBoundToken requests a bound, time-limited token for the named ServiceAccount from the
+// controller's configured BoundTokenMinter, using the audiences and TTL configured on the
+// controller.
+func (e *DockercfgController) mintBoundToken(namespace, saName string) (string, time.Time, error) {
+	return e.tokenMinter.MintToken(namespace, saName, e.tokenAudiences, e.tokenTTLSeconds)
+}
+
+// refreshBoundTokens re-mints any bound-token dockercfg secret whose token is within
+// RefreshBefore of expiring, updating Data[DockerConfigKey] in place. Candidates come from
+// dockercfgSecretStore rather than a live List, so this sweep never hits the API server just to
+// find out whether anything is due.
+func (e *DockercfgController) refreshBoundTokens() {
+	if e.tokenMode != BoundTokenRequest {
+		return
+	}
+
+	now := time.Now()
+	for _, obj := range e.dockercfgSecretStore.List() {
+		dockercfgSecret := obj.(*api.Secret)
+
+		expiresAt, err := time.Parse(time.RFC3339, dockercfgSecret.Annotations[BoundTokenExpirationKey])
+		if err != nil {
+			continue
+		}
+		if expiresAt.Sub(now) > e.refreshBefore {
+			continue
+		}
+
+		saName := dockercfgSecret.Annotations[api.ServiceAccountNameKey]
+		if len(saName) == 0 {
+			continue
+		}
+
+		token, newExpiresAt, err := e.mintBoundToken(dockercfgSecret.Namespace, saName)
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("error refreshing bound token for secret %s/%s: %v", dockercfgSecret.Namespace, dockercfgSecret.Name, err))
+			continue
+		}
+
+		if err := e.updateBoundTokenSecret(dockercfgSecret.Namespace, dockercfgSecret.Name, token, newExpiresAt); err != nil {
+			utilruntime.HandleError(fmt.Errorf("error updating secret %s/%s with refreshed bound token: %v", dockercfgSecret.Namespace, dockercfgSecret.Name, err))
+		}
+	}
+}
+
+// updateBoundTokenSecret overlays a freshly-minted token onto a dockercfg secret's existing
+// DockerConfig, preserving entries contributed by other providers, and refreshes the
+// secret's expiration annotation. It re-fetches the secret and retries on conflict, matching
+// the discipline createDockerPullSecretReference and removeSecretReference use elsewhere in
+// this controller, since the secret handed to refreshBoundTokens comes from the cached store
+// and may be stale by the time the update is sent.
+func (e *DockercfgController) updateBoundTokenSecret(namespace, name, token string, expiresAt time.Time) error {
+	e.dockerURLLock.Lock()
+	dockerURL := e.dockerURL
+	e.dockerURLLock.Unlock()
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		dockercfgSecret, err := e.client.Secrets(namespace).Get(name)
+		if err != nil {
+			return err
+		}
+
+		existing := credentialprovider.DockerConfig{}
+		if err := json.Unmarshal(dockercfgSecret.Data[api.DockerConfigKey], &existing); err != nil {
+			return err
+		}
+
+		existing[dockerURL] = credentialprovider.DockerConfigEntry{
+			Username: "serviceaccount",
+			Password: token,
+			Email:    "serviceaccount@example.org",
+		}
+
+		merged, err := json.Marshal(&existing)
+		if err != nil {
+			return err
+		}
+
+		dockercfgSecret.Data[api.DockerConfigKey] = merged
+		dockercfgSecret.Annotations[BoundTokenExpirationKey] = expiresAt.Format(time.RFC3339)
+
+		_, err = e.client.Secrets(namespace).Update(dockercfgSecret)
+		return err
+	})
+}
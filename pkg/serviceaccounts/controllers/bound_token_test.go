@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// stubMinter is a BoundTokenMinter that records its arguments and returns a fixed token and
+// expiration, so tests can exercise mintBoundToken/refreshBoundTokens without a live
+// TokenRequest-capable API server.
+type stubMinter struct {
+	token     string
+	expiresAt time.Time
+
+	calls []stubMintCall
+}
+
+type stubMintCall struct {
+	namespace, saName string
+	audiences         []string
+	ttlSeconds        *int64
+}
+
+func (m *stubMinter) MintToken(namespace, saName string, audiences []string, ttlSeconds *int64) (string, time.Time, error) {
+	m.calls = append(m.calls, stubMintCall{namespace: namespace, saName: saName, audiences: audiences, ttlSeconds: ttlSeconds})
+	return m.token, m.expiresAt, nil
+}
+
+// TestMintBoundTokenPassesConfiguredAudiencesAndTTL verifies mintBoundToken forwards the
+// controller's configured audiences and TTL, rather than the minter inventing its own.
+func TestMintBoundTokenPassesConfiguredAudiencesAndTTL(t *testing.T) {
+	ttl := int64(3600)
+	minter := &stubMinter{token: "tok", expiresAt: time.Now().Add(time.Hour)}
+	e := &DockercfgController{
+		tokenMinter:     minter,
+		tokenAudiences:  []string{"openshift"},
+		tokenTTLSeconds: &ttl,
+	}
+
+	token, expiresAt, err := e.mintBoundToken("ns", "default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "tok" {
+		t.Errorf("expected token %q, got %q", "tok", token)
+	}
+	if !expiresAt.Equal(minter.expiresAt) {
+		t.Errorf("expected expiresAt %v, got %v", minter.expiresAt, expiresAt)
+	}
+	if len(minter.calls) != 1 {
+		t.Fatalf("expected exactly 1 call to the minter, got %d", len(minter.calls))
+	}
+	call := minter.calls[0]
+	if call.namespace != "ns" || call.saName != "default" {
+		t.Errorf("expected namespace/name ns/default, got %s/%s", call.namespace, call.saName)
+	}
+	if len(call.audiences) != 1 || call.audiences[0] != "openshift" {
+		t.Errorf("expected audiences [openshift], got %v", call.audiences)
+	}
+	if call.ttlSeconds == nil || *call.ttlSeconds != ttl {
+		t.Errorf("expected ttlSeconds %d, got %v", ttl, call.ttlSeconds)
+	}
+}
+
+// TestRefreshBoundTokensSkipsNoOpTokenMode verifies refreshBoundTokens is a no-op outside of
+// BoundTokenRequest mode, so it never mints tokens (or talks to the API) for LegacySecret
+// controllers.
+func TestRefreshBoundTokensSkipsNoOpTokenMode(t *testing.T) {
+	minter := &stubMinter{}
+	e := &DockercfgController{
+		tokenMode:   LegacySecret,
+		tokenMinter: minter,
+		client:      newFakeClient(),
+	}
+
+	e.refreshBoundTokens()
+
+	if len(minter.calls) != 0 {
+		t.Errorf("expected no minter calls in LegacySecret mode, got %d", len(minter.calls))
+	}
+}
+
+// TestRefreshBoundTokensRefreshesExpiringSecret verifies a dockercfg secret whose bound token
+// is within the RefreshBefore window gets re-minted and its expiration annotation updated,
+// while one with plenty of time left is left alone.
+func TestRefreshBoundTokensRefreshesExpiringSecret(t *testing.T) {
+	now := time.Now()
+
+	expiringSoon := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace: "ns",
+			Name:      "expiring",
+			Annotations: map[string]string{
+				api.ServiceAccountNameKey: "default",
+				BoundTokenExpirationKey:   now.Add(2 * time.Minute).Format(time.RFC3339),
+			},
+		},
+		Type: api.SecretTypeDockercfg,
+		Data: map[string][]byte{api.DockerConfigKey: []byte(`{}`)},
+	}
+	notYetDue := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace: "ns",
+			Name:      "not-due",
+			Annotations: map[string]string{
+				api.ServiceAccountNameKey: "default",
+				BoundTokenExpirationKey:   now.Add(24 * time.Hour).Format(time.RFC3339),
+			},
+		},
+		Type: api.SecretTypeDockercfg,
+		Data: map[string][]byte{api.DockerConfigKey: []byte(`{}`)},
+	}
+
+	newExpiry := now.Add(time.Hour)
+	minter := &stubMinter{token: "new-token", expiresAt: newExpiry}
+	e := &DockercfgController{
+		tokenMode:            BoundTokenRequest,
+		tokenMinter:          minter,
+		refreshBefore:        10 * time.Minute,
+		client:               newFakeClient(expiringSoon, notYetDue),
+		dockercfgSecretStore: newEmptySecretIndexer(),
+		dockerURL:            "docker-registry.default.svc:5000",
+	}
+	if err := e.dockercfgSecretStore.Add(expiringSoon); err != nil {
+		t.Fatalf("unexpected error seeding store: %v", err)
+	}
+	if err := e.dockercfgSecretStore.Add(notYetDue); err != nil {
+		t.Fatalf("unexpected error seeding store: %v", err)
+	}
+
+	e.refreshBoundTokens()
+
+	if len(minter.calls) != 1 {
+		t.Fatalf("expected exactly 1 refresh, got %d", len(minter.calls))
+	}
+	if minter.calls[0].saName != "default" {
+		t.Errorf("expected the refresh to target ServiceAccount default, got %q", minter.calls[0].saName)
+	}
+
+	updated, err := e.client.Secrets("ns").Get("expiring")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := updated.Annotations[BoundTokenExpirationKey]; got != newExpiry.Format(time.RFC3339) {
+		t.Errorf("expected expiration annotation to be updated to %v, got %v", newExpiry.Format(time.RFC3339), got)
+	}
+
+	untouched, err := e.client.Secrets("ns").Get("not-due")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := untouched.Annotations[BoundTokenExpirationKey]; got != notYetDue.Annotations[BoundTokenExpirationKey] {
+		t.Errorf("expected the not-yet-due secret's expiration to be untouched, got %v", got)
+	}
+}
@@ -1,32 +1,31 @@
 package controllers
 
 import (
-	"encoding/json"
-	"fmt"
-	"reflect"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang/glog"
+
 	"k8s.io/kubernetes/pkg/api"
 	kapierrors "k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/client/cache"
+	"k8s.io/kubernetes/pkg/client/retry"
+	"k8s.io/kubernetes/pkg/client/typed/discovery"
 	client "k8s.io/kubernetes/pkg/client/unversioned"
 	"k8s.io/kubernetes/pkg/controller/framework"
-	"k8s.io/kubernetes/pkg/credentialprovider"
+	"k8s.io/kubernetes/pkg/fields"
 	"k8s.io/kubernetes/pkg/registry/secret"
 	"k8s.io/kubernetes/pkg/runtime"
 	utilruntime "k8s.io/kubernetes/pkg/util/runtime"
 	"k8s.io/kubernetes/pkg/util/sets"
 	"k8s.io/kubernetes/pkg/util/wait"
+	"k8s.io/kubernetes/pkg/util/workqueue"
 	"k8s.io/kubernetes/pkg/watch"
 
 	osautil "github.com/openshift/origin/pkg/serviceaccounts/util"
 )
 
-const ServiceAccountTokenSecretNameKey = "openshift.io/token-secret.name"
-
 // DockercfgControllerOptions contains options for the DockercfgController
 type DockercfgControllerOptions struct {
 	// Resync is the time.Duration at which to fully re-list service accounts.
@@ -34,12 +33,82 @@ type DockercfgControllerOptions struct {
 	Resync time.Duration
 
 	DefaultDockerURL string
+
+	// Providers are consulted, in addition to the built-in ServiceAccount-token provider,
+	// when assembling the dockercfg entries for a generated secret. A provider whose
+	// Enabled() returns false is skipped silently.
+	Providers []DockerCredentialProvider
+
+	// TokenMode selects how the bearer credential embedded in generated dockercfg secrets is
+	// obtained. Defaults to LegacySecret.
+	TokenMode TokenMode
+
+	// TokenAudiences and TokenTTLSeconds configure the token minted in BoundTokenRequest
+	// mode. They're ignored in LegacySecret mode.
+	TokenAudiences  []string
+	TokenTTLSeconds *int64
+
+	// RefreshBefore is how long before a bound token's expiration the refresh loop re-mints
+	// it. Defaults to boundTokenDefaultRefreshBefore if zero.
+	RefreshBefore time.Duration
+
+	// TokenMinter mints the bound tokens embedded in dockercfg secrets when TokenMode is
+	// BoundTokenRequest. If it's nil, NewDockercfgController defaults it to a minter backed by
+	// the TokenRequest subresource of the client passed to it.
+	TokenMinter BoundTokenMinter
+
+	// Discovery is used to confirm the API server supports the TokenRequest API before
+	// honoring TokenMode: BoundTokenRequest. If it's nil, or discovery fails, the controller
+	// falls back to LegacySecret and logs a warning.
+	Discovery discovery.DiscoveryInterface
+}
+
+// boundTokenDefaultRefreshBefore is the default RefreshBefore window when none is configured.
+const boundTokenDefaultRefreshBefore = 10 * time.Minute
+
+// secretListWatch returns a ListWatch scoped to a single secret type, so the informers built on
+// top of it only ever list/watch the generated secrets this controller cares about.
+func secretListWatch(cl client.Interface, secretType api.SecretType) *cache.ListWatch {
+	selector := fields.OneTermEqualSelector("type", string(secretType))
+	return &cache.ListWatch{
+		ListFunc: func(options api.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return cl.Secrets(api.NamespaceAll).List(options)
+		},
+		WatchFunc: func(options api.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return cl.Secrets(api.NamespaceAll).Watch(options)
+		},
+	}
 }
 
 // NewDockercfgController returns a new *DockercfgController.
 func NewDockercfgController(cl client.Interface, options DockercfgControllerOptions) *DockercfgController {
+	refreshBefore := options.RefreshBefore
+	if refreshBefore <= 0 {
+		refreshBefore = boundTokenDefaultRefreshBefore
+	}
+
+	tokenMode := options.TokenMode
+	tokenMinter := options.TokenMinter
+	if tokenMode == BoundTokenRequest {
+		if !boundTokenRequestSupported(options.Discovery) {
+			glog.Warningf("TokenMode BoundTokenRequest was requested, but the API server does not advertise TokenRequest support; falling back to LegacySecret")
+			tokenMode = LegacySecret
+		} else if tokenMinter == nil {
+			tokenMinter = &tokenRequestMinter{client: cl}
+		}
+	}
+
 	e := &DockercfgController{
-		client: cl,
+		client:           cl,
+		queue:            workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		providerRegistry: options.Providers,
+		tokenMode:        tokenMode,
+		tokenAudiences:   options.TokenAudiences,
+		tokenTTLSeconds:  options.TokenTTLSeconds,
+		tokenMinter:      tokenMinter,
+		refreshBefore:    refreshBefore,
 	}
 
 	_, e.serviceAccountController = framework.NewInformer(
@@ -54,11 +123,40 @@ func NewDockercfgController(cl client.Interface, options DockercfgControllerOpti
 		&api.ServiceAccount{},
 		options.Resync,
 		framework.ResourceEventHandlerFuncs{
-			AddFunc:    e.serviceAccountAdded,
-			UpdateFunc: e.serviceAccountUpdated,
+			AddFunc:    e.enqueueServiceAccount,
+			UpdateFunc: func(oldObj, newObj interface{}) { e.enqueueServiceAccount(newObj) },
+			DeleteFunc: e.serviceAccountDeleted,
 		},
 	)
 
+	// The field selector API only supports AND-ing terms together, so there's no way to ask
+	// the server for "type=ServiceAccountToken OR type=dockercfg" in one selector. Run one
+	// type-filtered informer per generated secret type instead of watching (and caching) every
+	// secret in the cluster.
+	e.tokenSecretStore, e.tokenSecretController = framework.NewIndexerInformer(
+		secretListWatch(e.client, api.SecretTypeServiceAccountToken),
+		&api.Secret{},
+		options.Resync,
+		framework.ResourceEventHandlerFuncs{
+			AddFunc:    e.enqueueServiceAccountForSecret,
+			UpdateFunc: func(oldObj, newObj interface{}) { e.enqueueServiceAccountForSecret(newObj) },
+			DeleteFunc: e.secretDeleted,
+		},
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+
+	e.dockercfgSecretStore, e.dockercfgSecretController = framework.NewIndexerInformer(
+		secretListWatch(e.client, api.SecretTypeDockercfg),
+		&api.Secret{},
+		options.Resync,
+		framework.ResourceEventHandlerFuncs{
+			AddFunc:    e.enqueueServiceAccountForSecret,
+			UpdateFunc: func(oldObj, newObj interface{}) { e.enqueueServiceAccountForSecret(newObj) },
+			DeleteFunc: e.secretDeleted,
+		},
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+
 	e.dockerURL = options.DefaultDockerURL
 
 	return e
@@ -74,14 +172,62 @@ type DockercfgController struct {
 	dockerURLLock sync.Mutex
 
 	serviceAccountController *framework.Controller
+
+	// tokenSecretController/tokenSecretStore and dockercfgSecretController/dockercfgSecretStore
+	// track the two generated secret types via separate type-filtered informers (the field
+	// selector API can't OR the two types together), so that workers can look up generated
+	// secrets without hitting the live API.
+	tokenSecretController *framework.Controller
+	tokenSecretStore      cache.Indexer
+
+	dockercfgSecretController *framework.Controller
+	dockercfgSecretStore      cache.Indexer
+
+	// queue holds namespace/name keys of ServiceAccounts that need their
+	// dockercfg secret reconciled.
+	queue workqueue.RateLimitingInterface
+
+	// providerRegistry holds the additional DockerCredentialProviders (beyond the built-in
+	// ServiceAccount-token provider) consulted when assembling a dockercfg secret.
+	providerRegistry []DockerCredentialProvider
+
+	// tokenMode, tokenAudiences, and tokenTTLSeconds configure how the controller obtains the
+	// bearer credential embedded in generated dockercfg secrets. See TokenMode.
+	tokenMode       TokenMode
+	tokenAudiences  []string
+	tokenTTLSeconds *int64
+
+	// tokenMinter mints bound tokens when tokenMode is BoundTokenRequest. Unused in LegacySecret mode.
+	tokenMinter BoundTokenMinter
+
+	// refreshBefore is how long before a bound token's expiration refreshBoundTokens re-mints it.
+	refreshBefore time.Duration
 }
 
-// Runs controller loops and returns immediately
-func (e *DockercfgController) Run() {
-	if e.stopChan == nil {
-		e.stopChan = make(chan struct{})
-		go e.serviceAccountController.Run(e.stopChan)
+// Run starts the controller's informers and blocks until their caches have synced, then starts
+// the requested number of worker goroutines draining the queue along with the background
+// refresh loops, and returns.
+func (e *DockercfgController) Run(workers int) {
+	if e.stopChan != nil {
+		return
+	}
+	e.stopChan = make(chan struct{})
+
+	go e.serviceAccountController.Run(e.stopChan)
+	go e.tokenSecretController.Run(e.stopChan)
+	go e.dockercfgSecretController.Run(e.stopChan)
+
+	if !cache.WaitForCacheSync(e.stopChan, e.serviceAccountController.HasSynced, e.tokenSecretController.HasSynced, e.dockercfgSecretController.HasSynced) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(e.worker, time.Second, e.stopChan)
 	}
+
+	go wait.Until(e.reportQueueDepth, 10*time.Second, e.stopChan)
+	go wait.Until(e.refreshProviderCredentials, providerRefreshCheckInterval, e.stopChan)
+	go wait.Until(e.refreshBoundTokens, boundTokenRefreshCheckInterval, e.stopChan)
 }
 
 // Stop gracefully shuts down this controller
@@ -90,6 +236,7 @@ func (e *DockercfgController) Stop() {
 		close(e.stopChan)
 		e.stopChan = nil
 	}
+	e.queue.ShutDown()
 }
 
 func (e *DockercfgController) SetDockerURL(newDockerURL string) {
@@ -99,22 +246,22 @@ func (e *DockercfgController) SetDockerURL(newDockerURL string) {
 	e.dockerURL = newDockerURL
 }
 
-// serviceAccountAdded reacts to a ServiceAccount creation by creating a corresponding ServiceAccountToken Secret
-func (e *DockercfgController) serviceAccountAdded(obj interface{}) {
-	serviceAccount := obj.(*api.ServiceAccount)
-
-	if err := e.createDockercfgSecretIfNeeded(serviceAccount); err != nil {
-		utilruntime.HandleError(err)
+// syncServiceAccount looks up the live ServiceAccount and reconciles its dockercfg secret.
+func (e *DockercfgController) syncServiceAccount(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
 	}
-}
 
-// serviceAccountUpdated reacts to a ServiceAccount update (or re-list) by ensuring a corresponding ServiceAccountToken Secret exists
-func (e *DockercfgController) serviceAccountUpdated(oldObj interface{}, newObj interface{}) {
-	newServiceAccount := newObj.(*api.ServiceAccount)
-
-	if err := e.createDockercfgSecretIfNeeded(newServiceAccount); err != nil {
-		utilruntime.HandleError(err)
+	serviceAccount, err := e.client.ServiceAccounts(namespace).Get(name)
+	if kapierrors.IsNotFound(err) {
+		return nil
 	}
+	if err != nil {
+		return err
+	}
+
+	return e.createDockercfgSecretIfNeeded(serviceAccount)
 }
 
 // createDockercfgSecretIfNeeded makes sure at least one ServiceAccountToken secret exists, and is included in the serviceAccount's Secrets list
@@ -140,13 +287,7 @@ func (e *DockercfgController) createDockercfgSecretIfNeeded(serviceAccount *api.
 			dockercfgSecretName = mountableDockercfgSecrets.List()[0]
 		}
 
-		err := e.createDockerPullSecretReference(serviceAccount, dockercfgSecretName)
-		if kapierrors.IsConflict(err) {
-			// nothing to do.  Our choice was stale or we got a conflict.  Either way that means that the service account was updated.  We simply need to return because we'll get an update notification later
-			return nil
-		}
-
-		return err
+		return e.createDockerPullSecretReference(serviceAccount, dockercfgSecretName)
 
 	}
 
@@ -162,10 +303,15 @@ func (e *DockercfgController) createDockercfgSecretIfNeeded(serviceAccount *api.
 		return nil
 	}
 
-	dockercfgSecret, err := e.createDockerPullSecret(serviceAccount)
+	dockercfgSecret, created, err := e.createDockerPullSecret(serviceAccount)
 	if err != nil {
 		return err
 	}
+	if !created {
+		// the token secret isn't populated yet; errTokenNotYetPopulated tells the worker to
+		// requeue with backoff without logging an error or counting it as a retry.
+		return errTokenNotYetPopulated
+	}
 
 	err = e.createDockerPullSecretReference(serviceAccount, dockercfgSecret.Name)
 	if kapierrors.IsConflict(err) {
@@ -183,46 +329,70 @@ func (e *DockercfgController) createDockercfgSecretIfNeeded(serviceAccount *api.
 
 // createDockerPullSecretReference updates a service account to reference the dockercfgSecret as a Secret and an ImagePullSecret
 func (e *DockercfgController) createDockerPullSecretReference(staleServiceAccount *api.ServiceAccount, dockercfgSecretName string) error {
-	liveServiceAccount, err := e.client.ServiceAccounts(staleServiceAccount.Namespace).Get(staleServiceAccount.Name)
-	if err != nil {
-		return err
-	}
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		liveServiceAccount, err := e.client.ServiceAccounts(staleServiceAccount.Namespace).Get(staleServiceAccount.Name)
+		if err != nil {
+			return err
+		}
 
-	mountableDockercfgSecrets, imageDockercfgPullSecrets := getGeneratedDockercfgSecretNames(liveServiceAccount)
-	staleDockercfgMountableSecrets, staleImageDockercfgPullSecrets := getGeneratedDockercfgSecretNames(staleServiceAccount)
+		mountableDockercfgSecrets, imageDockercfgPullSecrets := getGeneratedDockercfgSecretNames(liveServiceAccount)
 
-	// if we're trying to create a reference based on stale lists of dockercfg secrets, let the caller know
-	if !reflect.DeepEqual(staleDockercfgMountableSecrets.List(), mountableDockercfgSecrets.List()) || !reflect.DeepEqual(staleImageDockercfgPullSecrets.List(), imageDockercfgPullSecrets.List()) {
-		return kapierrors.NewConflict(api.Resource("serviceaccount"), staleServiceAccount.Name, fmt.Errorf("cannot add reference to %s based on stale data.  decision made for %v,%v, but live version is %v,%v", dockercfgSecretName, staleDockercfgMountableSecrets.List(), staleImageDockercfgPullSecrets.List(), mountableDockercfgSecrets.List(), imageDockercfgPullSecrets.List()))
-	}
+		changed := false
+		if !mountableDockercfgSecrets.Has(dockercfgSecretName) {
+			liveServiceAccount.Secrets = append(liveServiceAccount.Secrets, api.ObjectReference{Name: dockercfgSecretName})
+			changed = true
+		}
 
-	changed := false
-	if !mountableDockercfgSecrets.Has(dockercfgSecretName) {
-		liveServiceAccount.Secrets = append(liveServiceAccount.Secrets, api.ObjectReference{Name: dockercfgSecretName})
-		changed = true
-	}
+		if !imageDockercfgPullSecrets.Has(dockercfgSecretName) {
+			liveServiceAccount.ImagePullSecrets = append(liveServiceAccount.ImagePullSecrets, api.LocalObjectReference{Name: dockercfgSecretName})
+			changed = true
+		}
+
+		if !changed {
+			return nil
+		}
 
-	if !imageDockercfgPullSecrets.Has(dockercfgSecretName) {
-		liveServiceAccount.ImagePullSecrets = append(liveServiceAccount.ImagePullSecrets, api.LocalObjectReference{Name: dockercfgSecretName})
-		changed = true
+		_, err = e.client.ServiceAccounts(liveServiceAccount.Namespace).Update(liveServiceAccount)
+		return err
+	})
+}
+
+// findTokenSecret returns the token secret generated for this service account, populated or
+// not, if one exists in the secret lister, without hitting the live API.
+func (e *DockercfgController) findTokenSecret(serviceAccount *api.ServiceAccount) (*api.Secret, error) {
+	items, err := e.tokenSecretStore.ByIndex(cache.NamespaceIndex, serviceAccount.Namespace)
+	if err != nil {
+		return nil, err
 	}
 
-	if changed {
-		if _, err = e.client.ServiceAccounts(liveServiceAccount.Namespace).Update(liveServiceAccount); err != nil {
-			// TODO: retry on API conflicts in case the conflict was unrelated to our generated dockercfg secrets?
-			return err
+	prefix := osautil.GetTokenSecretNamePrefix(serviceAccount)
+	for _, obj := range items {
+		tokenSecret := obj.(*api.Secret)
+		if !strings.HasPrefix(tokenSecret.Name, prefix) {
+			continue
 		}
+		if tokenSecret.Annotations[api.ServiceAccountUIDKey] != string(serviceAccount.UID) {
+			continue
+		}
+		return tokenSecret, nil
 	}
-	return nil
+
+	return nil, nil
 }
 
-const (
-	tokenSecretWaitInterval = 20 * time.Millisecond
-	tokenSecretWaitTimes    = 100
-)
+// createTokenSecret creates a token secret for a given service account if one doesn't already
+// exist. The returned bool reports whether the token has been populated by the token controller
+// yet; callers should re-queue (with backoff) rather than block when it is false.
+func (e *DockercfgController) createTokenSecret(serviceAccount *api.ServiceAccount) (*api.Secret, bool, error) {
+	if existing, err := e.findTokenSecret(serviceAccount); err != nil {
+		return nil, false, err
+	} else if existing != nil {
+		if len(existing.Data[api.ServiceAccountTokenKey]) == 0 {
+			return nil, false, nil
+		}
+		return existing, true, nil
+	}
 
-// createTokenSecret creates a token secret for a given service account.  Returns the name of the token
-func (e *DockercfgController) createTokenSecret(serviceAccount *api.ServiceAccount) (*api.Secret, error) {
 	tokenSecret := &api.Secret{
 		ObjectMeta: api.ObjectMeta{
 			Name:      secret.Strategy.GenerateName(osautil.GetTokenSecretNamePrefix(serviceAccount)),
@@ -236,40 +406,48 @@ func (e *DockercfgController) createTokenSecret(serviceAccount *api.ServiceAccou
 		Data: map[string][]byte{},
 	}
 
-	_, err := e.client.Secrets(tokenSecret.Namespace).Create(tokenSecret)
+	created, err := e.client.Secrets(tokenSecret.Namespace).Create(tokenSecret)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	// now we have to wait for the service account token controller to make this valid
-	// TODO remove this once we have a create-token endpoint
-	for i := 0; i <= tokenSecretWaitTimes; i++ {
-		liveTokenSecret, err2 := e.client.Secrets(tokenSecret.Namespace).Get(tokenSecret.Name)
-		if err2 != nil {
-			return nil, err2
-		}
-
-		if len(liveTokenSecret.Data[api.ServiceAccountTokenKey]) > 0 {
-			return liveTokenSecret, nil
-		}
+	// the token controller populates Data[ServiceAccountTokenKey] asynchronously; our secret
+	// informer will notify us and re-queue this service account once that happens.
+	return created, false, nil
+}
 
-		time.Sleep(wait.Jitter(tokenSecretWaitInterval, 0.0))
+// createDockerPullSecret creates a dockercfg secret for the service account, dispatching to
+// the legacy token-secret flow or the bound TokenRequest flow depending on the controller's
+// configured TokenMode. The returned bool reports whether the dockercfg secret was actually
+// created; false means the caller should be re-queued rather than treat this as an error.
+func (e *DockercfgController) createDockerPullSecret(serviceAccount *api.ServiceAccount) (*api.Secret, bool, error) {
+	if e.tokenMode == BoundTokenRequest {
+		return e.createBoundDockerPullSecret(serviceAccount)
+	}
+	return e.createLegacyDockerPullSecret(serviceAccount)
+}
 
+// createLegacyDockerPullSecret creates a dockercfg secret based on a legacy, never-expiring
+// ServiceAccountToken secret. The returned bool mirrors createTokenSecret's: false means the
+// token secret exists but isn't populated yet.
+func (e *DockercfgController) createLegacyDockerPullSecret(serviceAccount *api.ServiceAccount) (*api.Secret, bool, error) {
+	tokenSecret, ready, err := e.createTokenSecret(serviceAccount)
+	if err != nil || !ready {
+		return nil, false, err
 	}
 
-	// the token wasn't ever created, attempt deletion
-	glog.Warningf("Deleting unfilled token secret %s/%s", tokenSecret.Namespace, tokenSecret.Name)
-	if deleteErr := e.client.Secrets(tokenSecret.Namespace).Delete(tokenSecret.Name); (deleteErr != nil) && !kapierrors.IsNotFound(deleteErr) {
-		utilruntime.HandleError(deleteErr)
+	// prevent updating the DockerURL until we've assembled the secret's providers
+	e.dockerURLLock.Lock()
+	saProvider := &saTokenCredentialProvider{
+		dockerURL: e.dockerURL,
+		token:     string(tokenSecret.Data[api.ServiceAccountTokenKey]),
 	}
-	return nil, fmt.Errorf("token never generated for %s", tokenSecret.Name)
-}
+	e.dockerURLLock.Unlock()
 
-// createDockerPullSecret creates a dockercfg secret based on the token secret
-func (e *DockercfgController) createDockerPullSecret(serviceAccount *api.ServiceAccount) (*api.Secret, error) {
-	tokenSecret, err := e.createTokenSecret(serviceAccount)
+	providers := append([]DockerCredentialProvider{saProvider}, e.providerRegistry...)
+	dockercfgContent, contributingProviders, err := mergeProviderDockerConfig(providers)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	dockercfgSecret := &api.Secret{
@@ -280,33 +458,69 @@ func (e *DockercfgController) createDockerPullSecret(serviceAccount *api.Service
 				api.ServiceAccountNameKey:        serviceAccount.Name,
 				api.ServiceAccountUIDKey:         string(serviceAccount.UID),
 				ServiceAccountTokenSecretNameKey: string(tokenSecret.Name),
+				DockercfgSecretProvidersKey:      strings.Join(contributingProviders, ","),
 			},
 		},
 		Type: api.SecretTypeDockercfg,
-		Data: map[string][]byte{},
+		Data: map[string][]byte{
+			api.DockerConfigKey: dockercfgContent,
+		},
+	}
+
+	// Save the secret
+	createdSecret, err := e.client.Secrets(tokenSecret.Namespace).Create(dockercfgSecret)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return createdSecret, true, nil
+}
+
+// createBoundDockerPullSecret mints a bound, time-limited token via the controller's
+// configured BoundTokenMinter and embeds it directly in the dockercfg secret, skipping the
+// legacy token-secret entirely.
+func (e *DockercfgController) createBoundDockerPullSecret(serviceAccount *api.ServiceAccount) (*api.Secret, bool, error) {
+	token, expiresAt, err := e.mintBoundToken(serviceAccount.Namespace, serviceAccount.Name)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// prevent updating the DockerURL until we've created the secret
 	e.dockerURLLock.Lock()
-	defer e.dockerURLLock.Unlock()
+	saProvider := &saTokenCredentialProvider{
+		dockerURL: e.dockerURL,
+		token:     token,
+	}
+	e.dockerURLLock.Unlock()
+
+	providers := append([]DockerCredentialProvider{saProvider}, e.providerRegistry...)
+	dockercfgContent, contributingProviders, err := mergeProviderDockerConfig(providers)
+	if err != nil {
+		return nil, false, err
+	}
 
-	dockercfg := &credentialprovider.DockerConfig{
-		e.dockerURL: credentialprovider.DockerConfigEntry{
-			Username: "serviceaccount",
-			Password: string(tokenSecret.Data[api.ServiceAccountTokenKey]),
-			Email:    "serviceaccount@example.org",
+	dockercfgSecret := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Name:      secret.Strategy.GenerateName(osautil.GetDockercfgSecretNamePrefix(serviceAccount)),
+			Namespace: serviceAccount.Namespace,
+			Annotations: map[string]string{
+				api.ServiceAccountNameKey:   serviceAccount.Name,
+				api.ServiceAccountUIDKey:    string(serviceAccount.UID),
+				DockercfgSecretProvidersKey: strings.Join(contributingProviders, ","),
+				BoundTokenExpirationKey:     expiresAt.Format(time.RFC3339),
+			},
+		},
+		Type: api.SecretTypeDockercfg,
+		Data: map[string][]byte{
+			api.DockerConfigKey: dockercfgContent,
 		},
 	}
-	dockercfgContent, err := json.Marshal(dockercfg)
+
+	createdSecret, err := e.client.Secrets(serviceAccount.Namespace).Create(dockercfgSecret)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	dockercfgSecret.Data[api.DockerConfigKey] = dockercfgContent
-
-	// Save the secret
-	createdSecret, err := e.client.Secrets(tokenSecret.Namespace).Create(dockercfgSecret)
 
-	return createdSecret, err
+	return createdSecret, true, nil
 }
 
 func getSecretReferences(serviceAccount *api.ServiceAccount) sets.String {
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"k8s.io/kubernetes/pkg/api"
+	kapierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/client/cache"
+	utilruntime "k8s.io/kubernetes/pkg/util/runtime"
+)
+
+const ServiceAccountTokenSecretNameKey = "openshift.io/token-secret.name"
+
+// generatedSecretsByNamespace returns every token and dockercfg secret cached for a namespace,
+// drawn from both type-filtered secret stores.
+func (e *DockercfgController) generatedSecretsByNamespace(namespace string) ([]*api.Secret, error) {
+	tokenItems, err := e.tokenSecretStore.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+	dockercfgItems, err := e.dockercfgSecretStore.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]*api.Secret, 0, len(tokenItems)+len(dockercfgItems))
+	for _, obj := range tokenItems {
+		secrets = append(secrets, obj.(*api.Secret))
+	}
+	for _, obj := range dockercfgItems {
+		secrets = append(secrets, obj.(*api.Secret))
+	}
+	return secrets, nil
+}
+
+// cleanupServiceAccountSecrets deletes every token and dockercfg secret this controller
+// generated for a ServiceAccount that no longer exists.
+func (e *DockercfgController) cleanupServiceAccountSecrets(deletedServiceAccount *api.ServiceAccount) error {
+	generatedSecrets, err := e.generatedSecretsByNamespace(deletedServiceAccount.Namespace)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, generatedSecret := range generatedSecrets {
+		if generatedSecret.Annotations[api.ServiceAccountUIDKey] != string(deletedServiceAccount.UID) {
+			continue
+		}
+		if err := e.client.Secrets(generatedSecret.Namespace).Delete(generatedSecret.Name); (err != nil) && !kapierrors.IsNotFound(err) {
+			utilruntime.HandleError(err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// cleanupGeneratedSecret removes the owning ServiceAccount's reference to a deleted generated
+// secret, and deletes whichever paired secret (token<->dockercfg) it was generated alongside.
+func (e *DockercfgController) cleanupGeneratedSecret(deleted *api.Secret) error {
+	if saName := deleted.Annotations[api.ServiceAccountNameKey]; len(saName) > 0 {
+		if err := e.removeSecretReference(deleted.Namespace, saName, deleted.Name); err != nil {
+			return err
+		}
+	}
+
+	switch deleted.Type {
+	case api.SecretTypeDockercfg:
+		tokenSecretName := deleted.Annotations[ServiceAccountTokenSecretNameKey]
+		if len(tokenSecretName) == 0 {
+			return nil
+		}
+		if err := e.client.Secrets(deleted.Namespace).Delete(tokenSecretName); (err != nil) && !kapierrors.IsNotFound(err) {
+			return err
+		}
+
+	case api.SecretTypeServiceAccountToken:
+		dockercfgSecrets, err := e.findDockercfgSecretsForToken(deleted.Namespace, deleted.Name)
+		if err != nil {
+			return err
+		}
+		for _, dockercfgSecret := range dockercfgSecrets {
+			if err := e.client.Secrets(deleted.Namespace).Delete(dockercfgSecret.Name); (err != nil) && !kapierrors.IsNotFound(err) {
+				utilruntime.HandleError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// findDockercfgSecretsForToken returns the dockercfg secrets in a namespace whose
+// ServiceAccountTokenSecretNameKey annotation points at the given token secret.
+func (e *DockercfgController) findDockercfgSecretsForToken(namespace, tokenSecretName string) ([]*api.Secret, error) {
+	items, err := e.dockercfgSecretStore.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*api.Secret{}
+	for _, obj := range items {
+		candidate := obj.(*api.Secret)
+		if candidate.Annotations[ServiceAccountTokenSecretNameKey] == tokenSecretName {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches, nil
+}
+
+// NumServiceAccountRemoveReferenceRetries bounds the number of conflict retries when removing
+// a dangling secret reference from a ServiceAccount, matching the upstream tokens_controller's
+// retry budget for the same operation.
+const NumServiceAccountRemoveReferenceRetries = 10
+
+// removeSecretReference drops secretName from a ServiceAccount's Secrets and
+// ImagePullSecrets lists, retrying on update conflicts since the main add/update path may be
+// updating the same ServiceAccount concurrently.
+func (e *DockercfgController) removeSecretReference(namespace, saName, secretName string) error {
+	var lastErr error
+	for i := 0; i < NumServiceAccountRemoveReferenceRetries; i++ {
+		liveServiceAccount, err := e.client.ServiceAccounts(namespace).Get(saName)
+		if kapierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		changed := false
+
+		secrets := liveServiceAccount.Secrets[:0]
+		for _, s := range liveServiceAccount.Secrets {
+			if s.Name == secretName {
+				changed = true
+				continue
+			}
+			secrets = append(secrets, s)
+		}
+		liveServiceAccount.Secrets = secrets
+
+		pullSecrets := liveServiceAccount.ImagePullSecrets[:0]
+		for _, s := range liveServiceAccount.ImagePullSecrets {
+			if s.Name == secretName {
+				changed = true
+				continue
+			}
+			pullSecrets = append(pullSecrets, s)
+		}
+		liveServiceAccount.ImagePullSecrets = pullSecrets
+
+		if !changed {
+			return nil
+		}
+
+		_, err = e.client.ServiceAccounts(namespace).Update(liveServiceAccount)
+		if err == nil {
+			return nil
+		}
+		if !kapierrors.IsConflict(err) {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/cache"
+	utilruntime "k8s.io/kubernetes/pkg/util/runtime"
+)
+
+// errTokenNotYetPopulated is returned by createDockercfgSecretIfNeeded when the generated
+// token secret exists but hasn't been populated yet. It's not a failure: the secret informer
+// will notify the queue once the token controller populates it, so
+// processNextWorkItem requeues on this sentinel without logging it as an error or counting it
+// against the retry metric.
+var errTokenNotYetPopulated = errors.New("dockercfg: token secret not yet populated")
+
+func init() {
+	prometheus.MustRegister(dockercfgQueueDepth)
+	prometheus.MustRegister(dockercfgQueueRetries)
+}
+
+var (
+	dockercfgQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "openshift",
+		Subsystem: "dockercfg_controller",
+		Name:      "queue_depth",
+		Help:      "Number of service account keys currently queued for dockercfg reconciliation.",
+	})
+	dockercfgQueueRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "openshift",
+		Subsystem: "dockercfg_controller",
+		Name:      "queue_retries_total",
+		Help:      "Number of times a service account key was re-queued after a failed or incomplete reconciliation.",
+	})
+)
+
+func (e *DockercfgController) reportQueueDepth() {
+	dockercfgQueueDepth.Set(float64(e.queue.Len()))
+}
+
+// workItemKind distinguishes the kinds of work dockercfgWorkItem carries through the queue, so
+// a single worker pool can drain both ordinary reconciliation and secret-deletion GC without
+// either one blocking an informer's dispatch goroutine.
+type workItemKind int
+
+const (
+	// syncServiceAccountItem reconciles the dockercfg secret for the ServiceAccount named by key.
+	syncServiceAccountItem workItemKind = iota
+	// cleanupSecretItem cleans up after the deletion of a single generated secret.
+	cleanupSecretItem
+	// cleanupServiceAccountItem cleans up every generated secret for a deleted ServiceAccount.
+	cleanupServiceAccountItem
+)
+
+// dockercfgWorkItem is the unit of work queued for the controller's worker pool. Every handler
+// that previously did its work in-line in an informer callback (deletion GC in particular) now
+// just tags and enqueues an item instead, so that work runs on a worker goroutine like
+// everything else.
+type dockercfgWorkItem struct {
+	kind workItemKind
+
+	// key is the "namespace/name" of the ServiceAccount to reconcile, valid for
+	// syncServiceAccountItem only.
+	key string
+
+	// secret is the just-deleted generated secret to clean up after, valid for
+	// cleanupSecretItem only.
+	secret *api.Secret
+
+	// serviceAccount is the just-deleted ServiceAccount whose generated secrets need cleaning
+	// up, valid for cleanupServiceAccountItem only.
+	serviceAccount *api.ServiceAccount
+}
+
+// enqueueServiceAccount adds the namespace/name of a ServiceAccount to the queue.
+func (e *DockercfgController) enqueueServiceAccount(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	e.queue.Add(dockercfgWorkItem{kind: syncServiceAccountItem, key: key})
+}
+
+// enqueueServiceAccountForSecret re-enqueues the owning ServiceAccount whenever one of
+// its generated token secrets changes, so a worker can notice the token has been
+// populated without polling for it.
+func (e *DockercfgController) enqueueServiceAccountForSecret(obj interface{}) {
+	generatedSecret, ok := obj.(*api.Secret)
+	if !ok || !isGeneratedSecret(generatedSecret) {
+		return
+	}
+	saName := generatedSecret.Annotations[api.ServiceAccountNameKey]
+	if len(saName) == 0 {
+		return
+	}
+	e.queue.Add(dockercfgWorkItem{kind: syncServiceAccountItem, key: generatedSecret.Namespace + "/" + saName})
+}
+
+// isGeneratedSecret reports whether a secret is one this controller creates and tracks:
+// either a legacy ServiceAccount token secret or the dockercfg secret built on top of it.
+func isGeneratedSecret(s *api.Secret) bool {
+	return s.Type == api.SecretTypeServiceAccountToken || s.Type == api.SecretTypeDockercfg
+}
+
+// secretDeleted reacts to the deletion of a generated token or dockercfg secret by enqueueing
+// cleanup of its counterpart and any now-dangling reference from the owning ServiceAccount.
+// The cleanup itself does blocking API calls and retries, so it's queued for a worker rather
+// than run inline on the informer's single dispatch goroutine.
+func (e *DockercfgController) secretDeleted(obj interface{}) {
+	deletedSecret, ok := secretFromDeleteEvent(obj)
+	if !ok || !isGeneratedSecret(deletedSecret) {
+		return
+	}
+
+	e.queue.Add(dockercfgWorkItem{kind: cleanupSecretItem, secret: deletedSecret})
+}
+
+// serviceAccountDeleted reacts to a ServiceAccount's deletion by enqueueing deletion of every
+// token and dockercfg secret this controller generated for it, for the same reason
+// secretDeleted queues rather than deletes inline.
+func (e *DockercfgController) serviceAccountDeleted(obj interface{}) {
+	deletedServiceAccount, ok := serviceAccountFromDeleteEvent(obj)
+	if !ok {
+		return
+	}
+
+	e.queue.Add(dockercfgWorkItem{kind: cleanupServiceAccountItem, serviceAccount: deletedServiceAccount})
+}
+
+// secretFromDeleteEvent unwraps a Secret from an informer DeleteFunc event, which may arrive
+// as a cache.DeletedFinalStateUnknown tombstone if the delete was observed during a resync.
+func secretFromDeleteEvent(obj interface{}) (*api.Secret, bool) {
+	if s, ok := obj.(*api.Secret); ok {
+		return s, true
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		s, ok := tombstone.Obj.(*api.Secret)
+		return s, ok
+	}
+	return nil, false
+}
+
+// serviceAccountFromDeleteEvent unwraps a ServiceAccount from an informer DeleteFunc event,
+// handling the same tombstone case as secretFromDeleteEvent.
+func serviceAccountFromDeleteEvent(obj interface{}) (*api.ServiceAccount, bool) {
+	if sa, ok := obj.(*api.ServiceAccount); ok {
+		return sa, true
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		sa, ok := tombstone.Obj.(*api.ServiceAccount)
+		return sa, ok
+	}
+	return nil, false
+}
+
+// worker runs a worker thread that dequeues items, processes them, and marks them done.
+func (e *DockercfgController) worker() {
+	for e.processNextWorkItem() {
+	}
+}
+
+func (e *DockercfgController) processNextWorkItem() bool {
+	item, quit := e.queue.Get()
+	if quit {
+		return false
+	}
+	defer e.queue.Done(item)
+
+	workItem := item.(dockercfgWorkItem)
+
+	var err error
+	switch workItem.kind {
+	case cleanupSecretItem:
+		err = e.cleanupGeneratedSecret(workItem.secret)
+	case cleanupServiceAccountItem:
+		err = e.cleanupServiceAccountSecrets(workItem.serviceAccount)
+	default:
+		err = e.syncServiceAccount(workItem.key)
+	}
+
+	switch {
+	case err == nil:
+		e.queue.Forget(item)
+		return true
+
+	case err == errTokenNotYetPopulated:
+		// expected: the token controller hasn't populated the token secret yet. Requeue with backoff, but don't count this as a retry or log
+		// it as an error - we'll pick it up again once the secret informer notifies us.
+		glog.V(4).Infof("service account %q token not yet populated, requeuing", workItem.key)
+		e.queue.AddRateLimited(item)
+		return true
+
+	default:
+		dockercfgQueueRetries.Inc()
+		utilruntime.HandleError(fmt.Errorf("error processing work item %+v, retrying: %v", workItem, err))
+		e.queue.AddRateLimited(item)
+		return true
+	}
+}
@@ -0,0 +1,132 @@
+// Package ecr provides a controllers.DockerCredentialProvider that authenticates against
+// Amazon Elastic Container Registry using the instance's IAM role.
+package ecr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+
+	"k8s.io/kubernetes/pkg/credentialprovider"
+
+	"github.com/openshift/origin/pkg/serviceaccounts/controllers"
+)
+
+// tokenRefreshInterval is conservatively shorter than the ~12 hour lifetime of an ECR
+// authorization token, so a sweep always picks up a token with time left on it.
+const tokenRefreshInterval = 10 * time.Hour
+
+// refreshSkew is how far before its stated expiry a cached authorization token is treated as
+// stale, so Provide() never hands out a token that's about to be rejected mid-pull.
+const refreshSkew = 5 * time.Minute
+
+// Provider is a controllers.DockerCredentialProvider that authenticates against ECR
+// registries in a single AWS region using ambient IAM credentials (instance profile,
+// assumed role, or environment credentials resolved by the AWS SDK's default chain).
+//
+// GetAuthorizationToken is called at most once per token lifetime: the result is cached and
+// reused by both Enabled() and Provide() until it's within refreshSkew of expiring, since
+// every DockercfgController worker reconciling a ServiceAccount - and every
+// refreshProviderCredentials sweep - consults both methods.
+type Provider struct {
+	region string
+	ecr    ecrClient
+
+	cache tokenCache
+}
+
+// tokenCache guards the most recently fetched authorization token.
+type tokenCache struct {
+	sync.Mutex
+	data *ecr.AuthorizationData
+}
+
+// ecrClient is the subset of the generated ECR client this provider depends on, so tests
+// can substitute a fake.
+type ecrClient interface {
+	GetAuthorizationToken(*ecr.GetAuthorizationTokenInput) (*ecr.GetAuthorizationTokenOutput, error)
+}
+
+// NewProvider returns an ECR DockerCredentialProvider for the given AWS region. It uses the
+// AWS SDK's default credential chain (instance profile, shared config, or environment
+// variables), so no explicit credentials are required when running on EC2 with an IAM role.
+func NewProvider(region string) (*Provider, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{region: region, ecr: ecr.New(sess)}, nil
+}
+
+func (p *Provider) Name() string {
+	return fmt.Sprintf("ecr:%s", p.region)
+}
+
+func (p *Provider) RefreshInterval() time.Duration {
+	return tokenRefreshInterval
+}
+
+// Enabled reports whether a usable authorization token is cached or can be fetched. It returns
+// false rather than erroring so that clusters without an ECR IAM role attached simply skip
+// this provider.
+func (p *Provider) Enabled() bool {
+	_, err := p.authorizationData()
+	return err == nil
+}
+
+// Provide returns a single entry for the region's ECR proxy endpoint, authenticated with the
+// cached (or freshly fetched) basic-auth token.
+func (p *Provider) Provide() credentialprovider.DockerConfig {
+	data, err := p.authorizationData()
+	if err != nil {
+		return credentialprovider.DockerConfig{}
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(aws.StringValue(data.AuthorizationToken))
+	if err != nil {
+		return credentialprovider.DockerConfig{}
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return credentialprovider.DockerConfig{}
+	}
+
+	return credentialprovider.DockerConfig{
+		aws.StringValue(data.ProxyEndpoint): credentialprovider.DockerConfigEntry{
+			Username: parts[0],
+			Password: parts[1],
+			Email:    "not-used@example.org",
+		},
+	}
+}
+
+// authorizationData returns the cached authorization token if it still has more than
+// refreshSkew left on it, otherwise it calls GetAuthorizationToken once and caches the result.
+func (p *Provider) authorizationData() (*ecr.AuthorizationData, error) {
+	p.cache.Lock()
+	defer p.cache.Unlock()
+
+	if cached := p.cache.data; cached != nil && cached.ExpiresAt != nil && time.Now().Add(refreshSkew).Before(*cached.ExpiresAt) {
+		return cached, nil
+	}
+
+	output, err := p.ecr.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("ecr: no authorization data returned for region %s", p.region)
+	}
+
+	p.cache.data = output.AuthorizationData[0]
+	return p.cache.data, nil
+}
+
+var _ controllers.DockerCredentialProvider = &Provider{}
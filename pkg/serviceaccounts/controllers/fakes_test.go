@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"k8s.io/kubernetes/pkg/client/cache"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// newFakeClient returns a client.Interface backed by the standard fake object tracker, seeded
+// with objects, for exercising controller methods without a live API server.
+func newFakeClient(objects ...runtime.Object) client.Interface {
+	return testclient.NewSimpleFake(objects...)
+}
+
+// newEmptySecretIndexer builds an empty cache.Indexer of the shape tokenSecretStore and
+// dockercfgSecretStore use, for tests that construct a DockercfgController directly rather
+// than through NewDockercfgController (and so never run the real informers).
+func newEmptySecretIndexer() cache.Indexer {
+	return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
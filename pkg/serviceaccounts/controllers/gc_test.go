@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+func newGCTestController(objects ...runtime.Object) *DockercfgController {
+	return &DockercfgController{
+		client:               newFakeClient(objects...),
+		tokenSecretStore:     newEmptySecretIndexer(),
+		dockercfgSecretStore: newEmptySecretIndexer(),
+	}
+}
+
+// TestCleanupGeneratedSecretDockercfgDeleted verifies that deleting a dockercfg secret deletes
+// its paired token secret, identified via the ServiceAccountTokenSecretNameKey annotation.
+func TestCleanupGeneratedSecretDockercfgDeleted(t *testing.T) {
+	tokenSecret := &api.Secret{
+		ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "default-token-abc"},
+		Type:       api.SecretTypeServiceAccountToken,
+	}
+	dockercfgSecret := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "default-dockercfg-xyz",
+			Annotations: map[string]string{ServiceAccountTokenSecretNameKey: tokenSecret.Name},
+		},
+		Type: api.SecretTypeDockercfg,
+	}
+
+	e := newGCTestController(tokenSecret)
+
+	if err := e.cleanupGeneratedSecret(dockercfgSecret); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := e.client.Secrets("ns").Get(tokenSecret.Name); err == nil {
+		t.Errorf("expected the paired token secret to have been deleted")
+	}
+}
+
+// TestCleanupGeneratedSecretTokenDeleted verifies that deleting a token secret deletes every
+// dockercfg secret in the namespace that references it.
+func TestCleanupGeneratedSecretTokenDeleted(t *testing.T) {
+	tokenSecret := &api.Secret{
+		ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "default-token-abc"},
+		Type:       api.SecretTypeServiceAccountToken,
+	}
+	dockercfgSecret := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "default-dockercfg-xyz",
+			Annotations: map[string]string{ServiceAccountTokenSecretNameKey: tokenSecret.Name},
+		},
+		Type: api.SecretTypeDockercfg,
+	}
+	unrelatedDockercfgSecret := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "default-dockercfg-other",
+			Annotations: map[string]string{ServiceAccountTokenSecretNameKey: "some-other-token"},
+		},
+		Type: api.SecretTypeDockercfg,
+	}
+
+	e := newGCTestController(dockercfgSecret, unrelatedDockercfgSecret)
+	if err := e.dockercfgSecretStore.Add(dockercfgSecret); err != nil {
+		t.Fatalf("unexpected error seeding store: %v", err)
+	}
+	if err := e.dockercfgSecretStore.Add(unrelatedDockercfgSecret); err != nil {
+		t.Fatalf("unexpected error seeding store: %v", err)
+	}
+
+	if err := e.cleanupGeneratedSecret(tokenSecret); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := e.client.Secrets("ns").Get(dockercfgSecret.Name); err == nil {
+		t.Errorf("expected the referencing dockercfg secret to have been deleted")
+	}
+	if _, err := e.client.Secrets("ns").Get(unrelatedDockercfgSecret.Name); err != nil {
+		t.Errorf("expected the unrelated dockercfg secret to survive, got error: %v", err)
+	}
+}
+
+// TestCleanupServiceAccountSecrets verifies that deleting a ServiceAccount deletes every
+// generated secret (both types) stamped with its UID, and leaves secrets for other
+// ServiceAccounts alone.
+func TestCleanupServiceAccountSecrets(t *testing.T) {
+	deletedSA := &api.ServiceAccount{ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "deleted", UID: "uid-1"}}
+
+	ownedToken := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "deleted-token-abc",
+			Annotations: map[string]string{api.ServiceAccountUIDKey: "uid-1"},
+		},
+		Type: api.SecretTypeServiceAccountToken,
+	}
+	ownedDockercfg := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "deleted-dockercfg-xyz",
+			Annotations: map[string]string{api.ServiceAccountUIDKey: "uid-1"},
+		},
+		Type: api.SecretTypeDockercfg,
+	}
+	otherSAToken := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "other-token-abc",
+			Annotations: map[string]string{api.ServiceAccountUIDKey: "uid-2"},
+		},
+		Type: api.SecretTypeServiceAccountToken,
+	}
+
+	e := newGCTestController(ownedToken, ownedDockercfg, otherSAToken)
+	if err := e.tokenSecretStore.Add(ownedToken); err != nil {
+		t.Fatalf("unexpected error seeding store: %v", err)
+	}
+	if err := e.tokenSecretStore.Add(otherSAToken); err != nil {
+		t.Fatalf("unexpected error seeding store: %v", err)
+	}
+	if err := e.dockercfgSecretStore.Add(ownedDockercfg); err != nil {
+		t.Fatalf("unexpected error seeding store: %v", err)
+	}
+
+	if err := e.cleanupServiceAccountSecrets(deletedSA); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := e.client.Secrets("ns").Get(ownedToken.Name); err == nil {
+		t.Errorf("expected the owned token secret to have been deleted")
+	}
+	if _, err := e.client.Secrets("ns").Get(ownedDockercfg.Name); err == nil {
+		t.Errorf("expected the owned dockercfg secret to have been deleted")
+	}
+	if _, err := e.client.Secrets("ns").Get(otherSAToken.Name); err != nil {
+		t.Errorf("expected the other ServiceAccount's token secret to survive, got error: %v", err)
+	}
+}
+
+// TestFindDockercfgSecretsForToken verifies the namespace-scoped lookup used by
+// cleanupGeneratedSecret only matches dockercfg secrets referencing the given token.
+func TestFindDockercfgSecretsForToken(t *testing.T) {
+	matching := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "match",
+			Annotations: map[string]string{ServiceAccountTokenSecretNameKey: "token-a"},
+		},
+		Type: api.SecretTypeDockercfg,
+	}
+	nonMatching := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "no-match",
+			Annotations: map[string]string{ServiceAccountTokenSecretNameKey: "token-b"},
+		},
+		Type: api.SecretTypeDockercfg,
+	}
+
+	e := newGCTestController()
+	if err := e.dockercfgSecretStore.Add(matching); err != nil {
+		t.Fatalf("unexpected error seeding store: %v", err)
+	}
+	if err := e.dockercfgSecretStore.Add(nonMatching); err != nil {
+		t.Fatalf("unexpected error seeding store: %v", err)
+	}
+
+	found, err := e.findDockercfgSecretsForToken("ns", "token-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 1 || found[0].Name != "match" {
+		t.Errorf("expected exactly [match], got %v", found)
+	}
+}
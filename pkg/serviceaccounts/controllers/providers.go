@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/retry"
+	"k8s.io/kubernetes/pkg/credentialprovider"
+	utilruntime "k8s.io/kubernetes/pkg/util/runtime"
+	"k8s.io/kubernetes/pkg/util/sets"
+)
+
+// providerRefreshCheckInterval is how often refreshProviderCredentials sweeps dockercfg
+// secrets looking for providers whose RefreshInterval has elapsed.
+const providerRefreshCheckInterval = 1 * time.Minute
+
+// DockercfgSecretProvidersKey records, as a comma-separated list, the names of the
+// DockerCredentialProviders that contributed an entry to a generated dockercfg secret.
+const DockercfgSecretProvidersKey = "openshift.io/token-secret.providers"
+
+// dockercfgSecretRefreshedAtKey records a JSON-encoded map[string]string of provider name to
+// RFC3339 timestamp, one entry per provider that has refreshed its credentials on this
+// dockercfg secret. Tracking this per-provider (rather than a single secret-wide timestamp)
+// means one provider's short RefreshInterval doesn't mask another's longer one.
+const dockercfgSecretRefreshedAtKey = "openshift.io/token-secret.providers-refreshed-at"
+
+// decodeProviderRefreshTimestamps parses the dockercfgSecretRefreshedAtKey annotation. An
+// empty or malformed value decodes to an empty map, so every provider is treated as due.
+func decodeProviderRefreshTimestamps(raw string) map[string]time.Time {
+	timestamps := map[string]time.Time{}
+	if len(raw) == 0 {
+		return timestamps
+	}
+
+	encoded := map[string]string{}
+	if err := json.Unmarshal([]byte(raw), &encoded); err != nil {
+		return timestamps
+	}
+	for name, value := range encoded {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			timestamps[name] = t
+		}
+	}
+	return timestamps
+}
+
+// encodeProviderRefreshTimestamps marshals a provider-name-to-timestamp map for storage in the
+// dockercfgSecretRefreshedAtKey annotation.
+func encodeProviderRefreshTimestamps(timestamps map[string]time.Time) (string, error) {
+	encoded := make(map[string]string, len(timestamps))
+	for name, t := range timestamps {
+		encoded[name] = t.Format(time.RFC3339)
+	}
+	raw, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// DockerCredentialProvider supplies one or more registry credential entries to be merged
+// into a generated dockercfg secret. It mirrors credentialprovider.DockerConfigProvider so
+// that providers written for the kubelet's credential plugins can be reused here.
+type DockerCredentialProvider interface {
+	// Name identifies the provider; it is recorded on generated secrets so a refresh loop
+	// knows which providers to re-invoke.
+	Name() string
+	// Enabled reports whether the provider is currently able to produce credentials (for
+	// example, false if no IAM role is attached). Disabled providers are skipped silently.
+	Enabled() bool
+	// Provide returns the registry entries this provider contributes.
+	Provide() credentialprovider.DockerConfig
+	// RefreshInterval returns how often Provide should be re-invoked to rotate short-lived
+	// credentials. A zero value means the entries never need to be refreshed.
+	RefreshInterval() time.Duration
+}
+
+// saTokenCredentialProvider is the built-in provider that reproduces the controller's
+// original behavior: a single entry for the internal registry, authenticated with the
+// ServiceAccount's own token.
+type saTokenCredentialProvider struct {
+	dockerURL string
+	token     string
+}
+
+func (p *saTokenCredentialProvider) Name() string                   { return "service-account-token" }
+func (p *saTokenCredentialProvider) Enabled() bool                  { return len(p.token) > 0 }
+func (p *saTokenCredentialProvider) RefreshInterval() time.Duration { return 0 }
+func (p *saTokenCredentialProvider) Provide() credentialprovider.DockerConfig {
+	return credentialprovider.DockerConfig{
+		p.dockerURL: credentialprovider.DockerConfigEntry{
+			Username: "serviceaccount",
+			Password: p.token,
+			Email:    "serviceaccount@example.org",
+		},
+	}
+}
+
+// mergeProviderDockerConfig merges the entries contributed by every enabled provider into a
+// single marshaled DockerConfig, along with the names of the providers that contributed.
+func mergeProviderDockerConfig(providers []DockerCredentialProvider) ([]byte, []string, error) {
+	merged := credentialprovider.DockerConfig{}
+	contributing := []string{}
+	for _, provider := range providers {
+		if !provider.Enabled() {
+			continue
+		}
+		for registry, entry := range provider.Provide() {
+			merged[registry] = entry
+		}
+		contributing = append(contributing, provider.Name())
+	}
+
+	dockercfgContent, err := json.Marshal(&merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dockercfgContent, contributing, nil
+}
+
+// refreshProviderCredentials periodically re-invokes Provide() for every registered provider
+// that declares a non-zero RefreshInterval, rewriting Data[DockerConfigKey] in place on every
+// dockercfg secret that provider contributed to. This keeps short-lived cloud registry tokens
+// (e.g. ECR's 12-hour auth tokens) current without regenerating the whole secret.
+//
+// It reads the candidate secrets from dockercfgSecretStore rather than the live API, the same
+// as refreshBoundTokens, so this sweep never hits the API server just to find out whether
+// anything is due.
+func (e *DockercfgController) refreshProviderCredentials() {
+	refreshable := []DockerCredentialProvider{}
+	for _, provider := range e.providerRegistry {
+		if provider.RefreshInterval() > 0 {
+			refreshable = append(refreshable, provider)
+		}
+	}
+	if len(refreshable) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, obj := range e.dockercfgSecretStore.List() {
+		dockercfgSecret := obj.(*api.Secret)
+		contributors := sets.NewString(strings.Split(dockercfgSecret.Annotations[DockercfgSecretProvidersKey], ",")...)
+		refreshedAtByProvider := decodeProviderRefreshTimestamps(dockercfgSecret.Annotations[dockercfgSecretRefreshedAtKey])
+
+		due := []DockerCredentialProvider{}
+		for _, provider := range refreshable {
+			if !contributors.Has(provider.Name()) {
+				continue
+			}
+			// A provider that's transiently unavailable (e.g. no IAM role right now) keeps
+			// its existing entry and its own timer untouched, so it's retried on the very
+			// next sweep instead of appearing "just refreshed".
+			if !provider.Enabled() {
+				continue
+			}
+			lastRefreshed, ok := refreshedAtByProvider[provider.Name()]
+			if !ok {
+				lastRefreshed = dockercfgSecret.CreationTimestamp.Time
+			}
+			if now.Sub(lastRefreshed) < provider.RefreshInterval() {
+				continue
+			}
+			due = append(due, provider)
+		}
+		if len(due) == 0 {
+			continue
+		}
+
+		dockercfgContent, _, err := mergeProviderDockerConfig(due)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+
+		if err := e.updateProviderRefreshedSecret(dockercfgSecret.Namespace, dockercfgSecret.Name, dockercfgContent, due, now); err != nil {
+			utilruntime.HandleError(fmt.Errorf("error refreshing credentials on secret %s/%s: %v", dockercfgSecret.Namespace, dockercfgSecret.Name, err))
+		}
+	}
+}
+
+// updateProviderRefreshedSecret overlays the entries contributed by the due providers onto a
+// dockercfg secret's existing DockerConfig, and advances each due provider's refresh timestamp.
+// Like updateBoundTokenSecret, it re-fetches the secret and retries on conflict rather than
+// updating the cached copy handed in by refreshProviderCredentials directly: that copy comes
+// from dockercfgSecretStore and may already be stale by the time the update is sent, and
+// refreshBoundTokens can be updating the very same secret concurrently on its own 1-minute
+// loop.
+func (e *DockercfgController) updateProviderRefreshedSecret(namespace, name string, refreshedContent []byte, due []DockerCredentialProvider, refreshedAt time.Time) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		dockercfgSecret, err := e.client.Secrets(namespace).Get(name)
+		if err != nil {
+			return err
+		}
+
+		existing := credentialprovider.DockerConfig{}
+		if err := json.Unmarshal(dockercfgSecret.Data[api.DockerConfigKey], &existing); err != nil {
+			return err
+		}
+		refreshed := credentialprovider.DockerConfig{}
+		if err := json.Unmarshal(refreshedContent, &refreshed); err != nil {
+			return err
+		}
+		for registry, entry := range refreshed {
+			existing[registry] = entry
+		}
+
+		merged, err := json.Marshal(&existing)
+		if err != nil {
+			return err
+		}
+
+		// Only the providers actually re-minted this pass advance their own timestamp; a
+		// provider skipped in refreshProviderCredentials (not yet due, or transiently
+		// disabled) keeps its prior timestamp so it's reconsidered on the next sweep rather
+		// than falsely marked fresh. Re-decoding from the freshly-fetched secret (rather than
+		// reusing the map refreshProviderCredentials built against the cached copy) keeps this
+		// correct across retries too.
+		refreshedAtByProvider := decodeProviderRefreshTimestamps(dockercfgSecret.Annotations[dockercfgSecretRefreshedAtKey])
+		for _, provider := range due {
+			refreshedAtByProvider[provider.Name()] = refreshedAt
+		}
+		encodedTimestamps, err := encodeProviderRefreshTimestamps(refreshedAtByProvider)
+		if err != nil {
+			return err
+		}
+
+		dockercfgSecret.Data[api.DockerConfigKey] = merged
+		dockercfgSecret.Annotations[dockercfgSecretRefreshedAtKey] = encodedTimestamps
+
+		_, err = e.client.Secrets(namespace).Update(dockercfgSecret)
+		return err
+	})
+}
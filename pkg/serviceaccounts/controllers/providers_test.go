@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/credentialprovider"
+)
+
+// fakeProvider is a DockerCredentialProvider stub for exercising mergeProviderDockerConfig and
+// refreshProviderCredentials without a real cloud provider.
+type fakeProvider struct {
+	name            string
+	enabled         bool
+	entries         credentialprovider.DockerConfig
+	refreshInterval time.Duration
+}
+
+func (p *fakeProvider) Name() string                             { return p.name }
+func (p *fakeProvider) Enabled() bool                            { return p.enabled }
+func (p *fakeProvider) RefreshInterval() time.Duration           { return p.refreshInterval }
+func (p *fakeProvider) Provide() credentialprovider.DockerConfig { return p.entries }
+
+// TestMergeProviderDockerConfig verifies entries from every enabled provider are merged into a
+// single DockerConfig, and disabled providers are skipped and excluded from the
+// contributing-providers list.
+func TestMergeProviderDockerConfig(t *testing.T) {
+	providers := []DockerCredentialProvider{
+		&fakeProvider{
+			name:    "sa-token",
+			enabled: true,
+			entries: credentialprovider.DockerConfig{
+				"internal-registry:5000": credentialprovider.DockerConfigEntry{Username: "serviceaccount", Password: "sa-token"},
+			},
+		},
+		&fakeProvider{
+			name:    "ecr",
+			enabled: true,
+			entries: credentialprovider.DockerConfig{
+				"123456789.dkr.ecr.us-east-1.amazonaws.com": credentialprovider.DockerConfigEntry{Username: "AWS", Password: "ecr-token"},
+			},
+		},
+		&fakeProvider{
+			name:    "disabled",
+			enabled: false,
+			entries: credentialprovider.DockerConfig{
+				"should-not-appear": credentialprovider.DockerConfigEntry{Username: "x", Password: "y"},
+			},
+		},
+	}
+
+	content, contributing, err := mergeProviderDockerConfig(providers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	merged := credentialprovider.DockerConfig{}
+	if err := json.Unmarshal(content, &merged); err != nil {
+		t.Fatalf("unexpected error unmarshaling merged config: %v", err)
+	}
+
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %v", len(merged), merged)
+	}
+	if _, ok := merged["internal-registry:5000"]; !ok {
+		t.Errorf("expected internal-registry:5000 entry")
+	}
+	if _, ok := merged["123456789.dkr.ecr.us-east-1.amazonaws.com"]; !ok {
+		t.Errorf("expected ecr entry")
+	}
+	if _, ok := merged["should-not-appear"]; ok {
+		t.Errorf("disabled provider's entry should not be merged")
+	}
+
+	wantContributing := []string{"sa-token", "ecr"}
+	if !reflect.DeepEqual(contributing, wantContributing) {
+		t.Errorf("expected contributing providers %v, got %v", wantContributing, contributing)
+	}
+}
+
+// TestMergeProviderDockerConfigCollision verifies that when two enabled providers contribute an
+// entry for the same registry, the later provider in the slice wins.
+func TestMergeProviderDockerConfigCollision(t *testing.T) {
+	providers := []DockerCredentialProvider{
+		&fakeProvider{
+			name:    "first",
+			enabled: true,
+			entries: credentialprovider.DockerConfig{
+				"registry.example.com": credentialprovider.DockerConfigEntry{Password: "first-token"},
+			},
+		},
+		&fakeProvider{
+			name:    "second",
+			enabled: true,
+			entries: credentialprovider.DockerConfig{
+				"registry.example.com": credentialprovider.DockerConfigEntry{Password: "second-token"},
+			},
+		},
+	}
+
+	content, _, err := mergeProviderDockerConfig(providers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	merged := credentialprovider.DockerConfig{}
+	if err := json.Unmarshal(content, &merged); err != nil {
+		t.Fatalf("unexpected error unmarshaling merged config: %v", err)
+	}
+	if got := merged["registry.example.com"].Password; got != "second-token" {
+		t.Errorf("expected the later provider to win the collision, got password %q", got)
+	}
+}
+
+// TestProviderRefreshTimestampRoundTrip verifies encodeProviderRefreshTimestamps and
+// decodeProviderRefreshTimestamps round-trip a per-provider timestamp map.
+func TestProviderRefreshTimestampRoundTrip(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	timestamps := map[string]time.Time{
+		"ecr": now,
+		"gcr": now.Add(-time.Hour),
+	}
+
+	encoded, err := encodeProviderRefreshTimestamps(timestamps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	decoded := decodeProviderRefreshTimestamps(encoded)
+	if len(decoded) != len(timestamps) {
+		t.Fatalf("expected %d timestamps, got %d", len(timestamps), len(decoded))
+	}
+	for name, want := range timestamps {
+		got, ok := decoded[name]
+		if !ok {
+			t.Errorf("missing timestamp for provider %q", name)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("provider %q: expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestDecodeProviderRefreshTimestampsMalformed(t *testing.T) {
+	cases := []string{"", "not-json", `{"ecr": "not-a-timestamp"}`}
+	for _, raw := range cases {
+		if decoded := decodeProviderRefreshTimestamps(raw); len(decoded) != 0 {
+			t.Errorf("decodeProviderRefreshTimestamps(%q): expected an empty map, got %v", raw, decoded)
+		}
+	}
+}
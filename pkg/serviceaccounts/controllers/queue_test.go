@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/workqueue"
+)
+
+func newQueueOnlyController() *DockercfgController {
+	return &DockercfgController{
+		queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+}
+
+// TestEnqueueServiceAccount verifies a ServiceAccount add/update is queued as a
+// syncServiceAccountItem keyed by its namespace/name.
+func TestEnqueueServiceAccount(t *testing.T) {
+	e := newQueueOnlyController()
+	e.enqueueServiceAccount(&api.ServiceAccount{
+		ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "default"},
+	})
+
+	if e.queue.Len() != 1 {
+		t.Fatalf("expected 1 item queued, got %d", e.queue.Len())
+	}
+	item, _ := e.queue.Get()
+	workItem := item.(dockercfgWorkItem)
+	if workItem.kind != syncServiceAccountItem {
+		t.Errorf("expected syncServiceAccountItem, got %v", workItem.kind)
+	}
+	if workItem.key != "ns/default" {
+		t.Errorf("expected key ns/default, got %q", workItem.key)
+	}
+}
+
+// TestEnqueueServiceAccountForSecret verifies only generated secrets that carry a
+// ServiceAccountNameKey annotation enqueue their owning ServiceAccount, and that
+// unrelated or incomplete secrets are ignored.
+func TestEnqueueServiceAccountForSecret(t *testing.T) {
+	cases := map[string]struct {
+		secret    *api.Secret
+		wantQueue bool
+	}{
+		"generated token secret": {
+			secret: &api.Secret{
+				ObjectMeta: api.ObjectMeta{
+					Namespace:   "ns",
+					Name:        "token-abc",
+					Annotations: map[string]string{api.ServiceAccountNameKey: "default"},
+				},
+				Type: api.SecretTypeServiceAccountToken,
+			},
+			wantQueue: true,
+		},
+		"opaque secret ignored": {
+			secret: &api.Secret{
+				ObjectMeta: api.ObjectMeta{
+					Namespace:   "ns",
+					Name:        "opaque",
+					Annotations: map[string]string{api.ServiceAccountNameKey: "default"},
+				},
+				Type: api.SecretTypeOpaque,
+			},
+			wantQueue: false,
+		},
+		"missing owner annotation ignored": {
+			secret: &api.Secret{
+				ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "dockercfg-xyz"},
+				Type:       api.SecretTypeDockercfg,
+			},
+			wantQueue: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := newQueueOnlyController()
+			e.enqueueServiceAccountForSecret(tc.secret)
+			if got := e.queue.Len() == 1; got != tc.wantQueue {
+				t.Errorf("expected queued=%v, got queue length %d", tc.wantQueue, e.queue.Len())
+			}
+		})
+	}
+}
+
+// TestSecretDeletedEnqueuesCleanup verifies secretDeleted no longer does blocking work
+// inline on the informer's dispatch goroutine - it only tags and enqueues the deleted
+// secret for a worker to clean up.
+func TestSecretDeletedEnqueuesCleanup(t *testing.T) {
+	e := newQueueOnlyController()
+	deleted := &api.Secret{
+		ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "dockercfg-xyz"},
+		Type:       api.SecretTypeDockercfg,
+	}
+
+	e.secretDeleted(deleted)
+
+	if e.queue.Len() != 1 {
+		t.Fatalf("expected 1 item queued, got %d", e.queue.Len())
+	}
+	item, _ := e.queue.Get()
+	workItem := item.(dockercfgWorkItem)
+	if workItem.kind != cleanupSecretItem {
+		t.Errorf("expected cleanupSecretItem, got %v", workItem.kind)
+	}
+	if workItem.secret != deleted {
+		t.Errorf("expected the deleted secret to be carried on the work item")
+	}
+}
+
+// TestServiceAccountDeletedEnqueuesCleanup mirrors TestSecretDeletedEnqueuesCleanup for
+// ServiceAccount deletion.
+func TestServiceAccountDeletedEnqueuesCleanup(t *testing.T) {
+	e := newQueueOnlyController()
+	deleted := &api.ServiceAccount{
+		ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "default"},
+	}
+
+	e.serviceAccountDeleted(deleted)
+
+	if e.queue.Len() != 1 {
+		t.Fatalf("expected 1 item queued, got %d", e.queue.Len())
+	}
+	item, _ := e.queue.Get()
+	workItem := item.(dockercfgWorkItem)
+	if workItem.kind != cleanupServiceAccountItem {
+		t.Errorf("expected cleanupServiceAccountItem, got %v", workItem.kind)
+	}
+	if workItem.serviceAccount != deleted {
+		t.Errorf("expected the deleted ServiceAccount to be carried on the work item")
+	}
+}
+
+// TestProcessNextWorkItemTokenNotYetPopulated verifies that when createDockercfgSecretIfNeeded
+// reports errTokenNotYetPopulated, processNextWorkItem requeues the item with rate-limited
+// backoff rather than straight back onto the ready queue.
+func TestProcessNextWorkItemTokenNotYetPopulated(t *testing.T) {
+	e := newQueueOnlyController()
+	serviceAccount := &api.ServiceAccount{ObjectMeta: api.ObjectMeta{Namespace: "ns", Name: "default"}}
+	e.client = newFakeClient(serviceAccount)
+	e.tokenSecretStore = newEmptySecretIndexer()
+	e.dockercfgSecretStore = newEmptySecretIndexer()
+
+	e.queue.Add(dockercfgWorkItem{kind: syncServiceAccountItem, key: "ns/default"})
+
+	before := e.queue.Len()
+	if !e.processNextWorkItem() {
+		t.Fatalf("expected processNextWorkItem to return true")
+	}
+	// errTokenNotYetPopulated requeues with backoff rather than immediately: the item
+	// shouldn't reappear in the ready queue synchronously, so the queue drains by one.
+	if e.queue.Len() != before-1 {
+		t.Errorf("expected queue length to drop to %d immediately after a rate-limited requeue, got %d", before-1, e.queue.Len())
+	}
+}